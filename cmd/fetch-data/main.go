@@ -7,7 +7,8 @@ import (
 )
 
 func main() {
-	c := nilan.Controller{Config: nilan.Config{NilanAddress: "192.168.1.31:502"}}
+	c := nilan.NewController(nilan.Config{NilanAddress: "192.168.1.31:502"})
+	defer c.Close()
 	errors, _ := c.FetchErrors()
 	fmt.Println(errors)
 }
@@ -3,87 +3,313 @@ package nilan
 import (
 	"encoding/binary"
 	"errors"
-	"fmt"
-	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/goburrow/modbus"
 )
 
+// defaultMaxRegisterReadGap is the largest address gap planRegisterReads
+// will bridge with a single ReadHoldingRegisters call, when
+// Controller.MaxRegisterReadGap is unset. Registers further apart than this
+// are fetched as separate Modbus transactions.
+const defaultMaxRegisterReadGap = 8
+
+// registerReadGroup is a span of registers fetched with one
+// ReadHoldingRegisters(start, count) call.
+type registerReadGroup struct {
+	start Register
+	count uint16
+}
+
+// planRegisterReads sorts registers and coalesces ones within maxGap
+// addresses of each other into the smallest set of contiguous
+// ReadHoldingRegisters windows, so FetchRegisterValues can read several
+// registers per Modbus transaction instead of one.
+func planRegisterReads(registers []Register, maxGap int) []registerReadGroup {
+	if len(registers) == 0 {
+		return nil
+	}
+
+	sorted := make([]Register, len(registers))
+	copy(sorted, registers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	groups := []registerReadGroup{{start: sorted[0], count: 1}}
+	for _, register := range sorted[1:] {
+		last := &groups[len(groups)-1]
+		groupEnd := last.start + Register(last.count) - 1
+		if register <= groupEnd {
+			continue
+		}
+		if int(register-groupEnd) <= maxGap {
+			last.count = uint16(register-last.start) + 1
+			continue
+		}
+		groups = append(groups, registerReadGroup{start: register, count: 1})
+	}
+	return groups
+}
+
+// defaultMaxRetries is how many times a transient Modbus error is retried
+// before a bus operation gives up, when Controller.MaxRetries is unset.
+const defaultMaxRetries = 3
+
+// defaultRetryBackoff is the base delay between retries when
+// Controller.RetryBackoff is unset. The delay doubles after every attempt.
+const defaultRetryBackoff = 500 * time.Millisecond
+
 // Controller is used for communicating with Nilan CTS700 heatpump over
-// Modbus TCP.
+// Modbus TCP. A Controller keeps one long-lived connection per Modbus
+// slave ID and is safe for concurrent use.
 type Controller struct {
 	Config Config
+
+	// MaxRetries is how many times a bus operation is retried after a
+	// transient error (e.g. a dropped TCP connection) before it gives up.
+	// Zero means defaultMaxRetries.
+	MaxRetries int
+	// RetryBackoff is the base delay between retries; it doubles after
+	// every attempt. Zero means defaultRetryBackoff.
+	RetryBackoff time.Duration
+	// MaxRegisterReadGap is the largest address gap FetchRegisterValues
+	// will bridge with a single ReadHoldingRegisters call; registers
+	// further apart are fetched as separate transactions. Zero means
+	// defaultMaxRegisterReadGap.
+	MaxRegisterReadGap int
+	// Logger receives debug/info/warn/error traces for bus activity and
+	// settings changes. Nil means logging is discarded.
+	Logger Logger
+	// OnTransaction, if set, is called after every Modbus transaction
+	// (read or write) with the slave ID, how long it took and the error
+	// it returned (nil on success). It lets callers such as nilanprom
+	// surface per-transaction latency as a metric without Controller
+	// depending on Prometheus.
+	OnTransaction func(slaveID byte, duration time.Duration, err error)
+
+	mu          sync.Mutex
+	connections map[byte]modbusConnection
+	// dial opens the connection for a slave ID. It is a field (rather
+	// than a direct modbus.NewTCPClientHandler call) so tests can swap in
+	// a fake connection without a live device.
+	dial func(slaveID byte) (modbusConnection, error)
 }
 
-func (c *Controller) getHandler(slaveID byte) *modbus.TCPClientHandler {
-	// Modbus TCP
-	handler := modbus.NewTCPClientHandler(c.Config.NilanAddress)
-	handler.Timeout = 10 * time.Second
-	handler.SlaveId = slaveID
-	err := handler.Connect()
+// modbusConnection is a modbus.Client bound to a connection that can be
+// torn down, so Controller can reconnect after a transient bus error.
+type modbusConnection interface {
+	modbus.Client
+	Close() error
+}
 
-	if err != nil {
-		panic(err)
-	}
+// tcpConnection adapts a modbus.TCPClientHandler (which packages both the
+// Packager/Transporter pair a client needs and the net.Conn it owns) into
+// a modbusConnection.
+type tcpConnection struct {
+	modbus.Client
+	handler *modbus.TCPClientHandler
+}
 
-	return handler
+func (t *tcpConnection) Close() error {
+	return t.handler.Close()
 }
 
-// FetchValue from register
-func (c *Controller) FetchValue(slaveID byte, register Register) (uint16, error) {
-	handler := c.getHandler(slaveID)
-	defer handler.Close()
-	client := modbus.NewClient(handler)
-	resultBytes, error := client.ReadHoldingRegisters(uint16(register), 1)
-	if error != nil {
-		return 0, error
+// NewController creates a Controller ready to talk to the Nilan unit
+// described by config.
+func NewController(config Config) *Controller {
+	return &Controller{
+		Config:             config,
+		MaxRetries:         defaultMaxRetries,
+		RetryBackoff:       defaultRetryBackoff,
+		MaxRegisterReadGap: defaultMaxRegisterReadGap,
+		Logger:             noopLogger{},
+		connections:        make(map[byte]modbusConnection),
+		dial: func(slaveID byte) (modbusConnection, error) {
+			handler := modbus.NewTCPClientHandler(config.NilanAddress)
+			handler.Timeout = 10 * time.Second
+			handler.SlaveId = slaveID
+			if err := handler.Connect(); err != nil {
+				return nil, err
+			}
+			return &tcpConnection{Client: modbus.NewClient(handler), handler: handler}, nil
+		},
 	}
-	if len(resultBytes) == 2 {
-		return binary.BigEndian.Uint16(resultBytes), nil
-	} else {
-		return 0, errors.New("cannot read register value")
+}
+
+// Dial eagerly opens (and caches) the Modbus TCP connection for slaveID.
+// Calling it is optional: bus operations dial lazily on first use.
+func (c *Controller) Dial(slaveID byte) error {
+	_, err := c.getClient(slaveID)
+	return err
+}
+
+// Close closes every connection opened by this Controller. The Controller
+// can be used again afterwards; it will simply reconnect lazily.
+func (c *Controller) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for slaveID, conn := range c.connections {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.connections, slaveID)
 	}
+	return firstErr
 }
 
-// FetchRegisterValues from slave
-func (c *Controller) FetchRegisterValues(slaveID byte, registers []Register) (map[Register]uint16, error) {
-	m := make(map[Register]uint16)
+// getClient returns a Modbus client for slaveID, dialing and caching the
+// underlying connection if it isn't already open. Callers must hold no
+// locks; getClient manages its own locking.
+func (c *Controller) getClient(slaveID byte) (modbus.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	handler := c.getHandler(slaveID)
-	defer handler.Close()
-	client := modbus.NewClient(handler)
+	if c.connections == nil {
+		c.connections = make(map[byte]modbusConnection)
+	}
 
-	for _, register := range registers {
-		resultBytes, err := client.ReadHoldingRegisters(uint16(register), 1)
+	conn, ok := c.connections[slaveID]
+	if !ok {
+		var err error
+		conn, err = c.dial(slaveID)
 		if err != nil {
-			return m, err
+			return nil, err
 		}
-		if len(resultBytes) == 2 {
-			resultWord := binary.BigEndian.Uint16(resultBytes)
-			m[register] = resultWord
+		c.connections[slaveID] = conn
+	}
+
+	return conn, nil
+}
+
+// dropHandler closes and forgets the cached connection for slaveID, forcing
+// the next getClient call to redial. It is used to recover from transient
+// bus errors.
+func (c *Controller) dropHandler(slaveID byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.connections[slaveID]; ok {
+		conn.Close()
+		delete(c.connections, slaveID)
+	}
+}
+
+// withRetry runs op against slaveID's client, reconnecting and retrying
+// with exponential backoff when op (or the dial preceding it) fails. It
+// times the whole attempt (dial included) and reports it through
+// OnTransaction and the debug log.
+func (c *Controller) withRetry(slaveID byte, op func(modbus.Client) error) error {
+	maxRetries := c.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	backoff := c.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		start := time.Now()
+		client, err := c.getClient(slaveID)
+		if err != nil {
+			lastErr = err
 		} else {
-			return m, errors.New("no result bytes")
+			lastErr = op(client)
+		}
+		duration := time.Since(start)
+
+		if c.OnTransaction != nil {
+			c.OnTransaction(slaveID, duration, lastErr)
+		}
+		c.logger().Debugf("modbus: slave %d transaction took %s (err=%v)", slaveID, duration, lastErr)
+
+		if lastErr == nil {
+			return nil
+		}
+
+		c.logger().Warnf("modbus: attempt %d/%d on slave %d failed: %v", attempt+1, maxRetries+1, slaveID, lastErr)
+		c.dropHandler(slaveID)
+		if attempt < maxRetries {
+			time.Sleep(backoff * (1 << attempt))
 		}
 	}
+	return lastErr
+}
 
-	return m, nil
+// FetchValue from register
+func (c *Controller) FetchValue(slaveID byte, register Register) (uint16, error) {
+	var value uint16
+	err := c.withRetry(slaveID, func(client modbus.Client) error {
+		resultBytes, err := client.ReadHoldingRegisters(uint16(register), 1)
+		if err != nil {
+			return err
+		}
+		if len(resultBytes) != 2 {
+			return errors.New("cannot read register value")
+		}
+		value = binary.BigEndian.Uint16(resultBytes)
+		c.logger().Debugf("modbus: slave %d register %d -> %d", slaveID, register, value)
+		return nil
+	})
+	return value, err
+}
+
+// FetchRegisterValues from slave. Registers that fall within
+// Controller.MaxRegisterReadGap addresses of each other are read together
+// in a single Modbus transaction.
+func (c *Controller) FetchRegisterValues(slaveID byte, registers []Register) (map[Register]uint16, error) {
+	m := make(map[Register]uint16)
+
+	wanted := make(map[Register]bool, len(registers))
+	for _, register := range registers {
+		wanted[register] = true
+	}
+
+	maxGap := c.MaxRegisterReadGap
+	if maxGap <= 0 {
+		maxGap = defaultMaxRegisterReadGap
+	}
+
+	err := c.withRetry(slaveID, func(client modbus.Client) error {
+		for _, group := range planRegisterReads(registers, maxGap) {
+			resultBytes, err := client.ReadHoldingRegisters(uint16(group.start), group.count)
+			if err != nil {
+				return err
+			}
+			if len(resultBytes) != int(group.count)*2 {
+				return errors.New("no result bytes")
+			}
+			for i := uint16(0); i < group.count; i++ {
+				register := group.start + Register(i)
+				if wanted[register] {
+					value := binary.BigEndian.Uint16(resultBytes[i*2 : i*2+2])
+					m[register] = value
+					c.logger().Debugf("modbus: slave %d register %d -> %d", slaveID, register, value)
+				}
+			}
+		}
+		return nil
+	})
+
+	return m, err
 }
 
 // SetRegisterValues on slave
 func (c *Controller) SetRegisterValues(slaveID byte, values map[Register]uint16) error {
-	handler := c.getHandler(slaveID)
-	defer handler.Close()
-	client := modbus.NewClient(handler)
-
-	for register, value := range values {
-		_, error := client.WriteSingleRegister(uint16(register), value)
-		if error != nil {
-			return error
+	return c.withRetry(slaveID, func(client modbus.Client) error {
+		for register, value := range values {
+			if _, err := client.WriteSingleRegister(uint16(register), value); err != nil {
+				return err
+			}
+			c.logger().Debugf("modbus: slave %d register %d <- %d", slaveID, register, value)
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // Register is address of register on client
@@ -244,10 +470,10 @@ func (c *Controller) FetchSettings() (*Settings, error) {
 	*fanSpeed = FanSpeed(client1RegisterValues[FanSpeedRegister])
 
 	desiredRoomTemperature := new(int)
-	*desiredRoomTemperature = int(client1RegisterValues[DesiredRoomTemperatureRegister])
+	*desiredRoomTemperature = int(int16(client1RegisterValues[DesiredRoomTemperatureRegister]))
 
 	desiredDHWTemperature := new(int)
-	*desiredDHWTemperature = int(client1RegisterValues[DHWSetPointRegister])
+	*desiredDHWTemperature = int(int16(client1RegisterValues[DHWSetPointRegister]))
 
 	dhwPaused := new(bool)
 	*dhwPaused = client1RegisterValues[DHWPauseRegister] == 1
@@ -271,7 +497,7 @@ func (c *Controller) FetchSettings() (*Settings, error) {
 	*ventilationPause = client1RegisterValues[VentilationPauseRegister] == 1
 
 	setpointTemperature := new(int)
-	*setpointTemperature = int(client4RegisterValues[supplyTemperatureRegister])
+	*setpointTemperature = int(int16(client4RegisterValues[supplyTemperatureRegister]))
 
 	settings := &Settings{FanSpeed: fanSpeed,
 		DesiredRoomTemperature:      desiredRoomTemperature,
@@ -291,7 +517,7 @@ func (c *Controller) FetchSettings() (*Settings, error) {
 // SendSettings of Nilan
 func (c *Controller) SendSettings(settings Settings) error {
 	settingsStr := spew.Sprintf("%+v", settings)
-	log.Printf("Sending new settings to Nialn (<nil> values will be ignored): %+v\n", settingsStr)
+	c.logger().Infof("Sending new settings to Nilan (<nil> values will be ignored): %+v", settingsStr)
 	client1RegisterValues := make(map[Register]uint16)
 	client4RegisterValues := make(map[Register]uint16)
 
@@ -430,13 +656,13 @@ func (c *Controller) FetchReadings() (*Readings, error) {
 		return nil, e2
 	}
 
-	roomTemperature := int(client1ReadingsRaw[roomTemperatureRegister])
-	outdoorTemperature := int(client1ReadingsRaw[OutdoorTemperatureRegister])
+	roomTemperature := int(int16(client1ReadingsRaw[roomTemperatureRegister]))
+	outdoorTemperature := int(int16(client1ReadingsRaw[OutdoorTemperatureRegister]))
 	averageHumidity := int(client1ReadingsRaw[AverageHumidityRegister])
 	actualHumidity := int(client1ReadingsRaw[ActualHumidityRegister])
-	dhwTopTemperature := int(client1ReadingsRaw[DHWTopTankTemperatureRegister])
-	dhwBottomTemperature := int(client1ReadingsRaw[DHWBottomTankTemperatureRegister])
-	supplyFlowTemperature := int(client4ReadingsRaw[t18Register])
+	dhwTopTemperature := int(int16(client1ReadingsRaw[DHWTopTankTemperatureRegister]))
+	dhwBottomTemperature := int(int16(client1ReadingsRaw[DHWBottomTankTemperatureRegister]))
+	supplyFlowTemperature := int(int16(client4ReadingsRaw[t18Register]))
 
 	readings := &Readings{
 		RoomTemperature:          roomTemperature,
@@ -448,7 +674,7 @@ func (c *Controller) FetchReadings() (*Readings, error) {
 		SupplyFlowTemperature:    supplyFlowTemperature}
 
 	if readings.AverageHumidity == 0 {
-		fmt.Println("what?")
+		c.logger().Warnf("FetchReadings: AverageHumidity read as 0 on slave 1 register %d, which looks suspicious", AverageHumidityRegister)
 	}
 
 	return readings, nil
@@ -0,0 +1,187 @@
+package nilan
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// fakeConnection is a modbusConnection stub that lets tests drive
+// Controller's retry/reconnect logic without a live device. Embedding a nil
+// modbus.Client means any method a test doesn't stub out panics instead of
+// silently succeeding.
+type fakeConnection struct {
+	modbus.Client
+	closed bool
+}
+
+func (f *fakeConnection) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newTestController() *Controller {
+	c := NewController(Config{NilanAddress: "fake:502"})
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestPlanRegisterReads(t *testing.T) {
+	cases := []struct {
+		name      string
+		registers []Register
+		maxGap    int
+		want      []registerReadGroup
+	}{
+		{"empty", nil, 8, nil},
+		{"single", []Register{10}, 8, []registerReadGroup{{start: 10, count: 1}}},
+		{"adjacent merges", []Register{10, 11, 12}, 8, []registerReadGroup{{start: 10, count: 3}}},
+		{"unsorted input merges", []Register{12, 10, 11}, 8, []registerReadGroup{{start: 10, count: 3}}},
+		{"duplicate registers collapse", []Register{10, 10, 11}, 8, []registerReadGroup{{start: 10, count: 2}}},
+		{
+			"gap within threshold merges",
+			[]Register{10, 18},
+			8,
+			[]registerReadGroup{{start: 10, count: 9}},
+		},
+		{
+			"gap beyond threshold splits",
+			[]Register{10, 19},
+			8,
+			[]registerReadGroup{{start: 10, count: 1}, {start: 19, count: 1}},
+		},
+		{
+			"smaller maxGap splits what a larger one would merge",
+			[]Register{10, 18},
+			7,
+			[]registerReadGroup{{start: 10, count: 1}, {start: 18, count: 1}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := planRegisterReads(tc.registers, tc.maxGap)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("planRegisterReads(%v, %d) = %+v, want %+v", tc.registers, tc.maxGap, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestControllerDialCachesConnectionPerSlave(t *testing.T) {
+	c := newTestController()
+	var dialed []byte
+	c.dial = func(slaveID byte) (modbusConnection, error) {
+		dialed = append(dialed, slaveID)
+		return &fakeConnection{}, nil
+	}
+
+	if err := c.Dial(1); err != nil {
+		t.Fatalf("Dial(1) = %v, want nil", err)
+	}
+	if err := c.Dial(1); err != nil {
+		t.Fatalf("second Dial(1) = %v, want nil", err)
+	}
+	if err := c.Dial(4); err != nil {
+		t.Fatalf("Dial(4) = %v, want nil", err)
+	}
+
+	if want := []byte{1, 4}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %v, want one dial per distinct slave: %v", dialed, want)
+	}
+}
+
+func TestControllerCloseClosesConnectionsAndAllowsRedial(t *testing.T) {
+	c := newTestController()
+	var conns []*fakeConnection
+	c.dial = func(slaveID byte) (modbusConnection, error) {
+		conn := &fakeConnection{}
+		conns = append(conns, conn)
+		return conn, nil
+	}
+
+	if err := c.Dial(1); err != nil {
+		t.Fatalf("Dial(1) = %v, want nil", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() = %v, want nil", err)
+	}
+	if !conns[0].closed {
+		t.Error("Close() did not close the cached connection")
+	}
+
+	if err := c.Dial(1); err != nil {
+		t.Fatalf("Dial(1) after Close() = %v, want nil", err)
+	}
+	if len(conns) != 2 {
+		t.Errorf("got %d connections, want a fresh dial after Close()", len(conns))
+	}
+}
+
+func TestControllerWithRetryRetriesTransientErrorsAndReconnects(t *testing.T) {
+	c := newTestController()
+	var conns []*fakeConnection
+	c.dial = func(slaveID byte) (modbusConnection, error) {
+		conn := &fakeConnection{}
+		conns = append(conns, conn)
+		return conn, nil
+	}
+
+	attempt := 0
+	err := c.withRetry(1, func(client modbus.Client) error {
+		attempt++
+		if attempt < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("withRetry() = %v, want nil", err)
+	}
+	if attempt != 3 {
+		t.Errorf("op called %d times, want 3", attempt)
+	}
+	if len(conns) != 3 {
+		t.Fatalf("dialed %d connections, want 3 (one redial per failed attempt)", len(conns))
+	}
+	for i, conn := range conns[:len(conns)-1] {
+		if !conn.closed {
+			t.Errorf("connection %d: want closed after its transaction failed", i)
+		}
+	}
+	if conns[len(conns)-1].closed {
+		t.Error("connection for the successful attempt should not be closed")
+	}
+}
+
+func TestControllerWithRetryGivesUpAfterMaxRetries(t *testing.T) {
+	c := newTestController()
+	c.MaxRetries = 2
+	dialCount := 0
+	c.dial = func(slaveID byte) (modbusConnection, error) {
+		dialCount++
+		return &fakeConnection{}, nil
+	}
+
+	wantErr := errors.New("boom")
+	start := time.Now()
+	err := c.withRetry(1, func(client modbus.Client) error { return wantErr })
+	elapsed := time.Since(start)
+
+	if err != wantErr {
+		t.Errorf("withRetry() = %v, want %v", err, wantErr)
+	}
+	if dialCount != c.MaxRetries+1 {
+		t.Errorf("dialed %d times, want %d (MaxRetries+1 attempts)", dialCount, c.MaxRetries+1)
+	}
+	// Backoff doubles after each failed attempt but the last: with
+	// MaxRetries=2 that's a sleep of RetryBackoff then 2*RetryBackoff.
+	wantMinElapsed := c.RetryBackoff + 2*c.RetryBackoff
+	if elapsed < wantMinElapsed {
+		t.Errorf("withRetry() took %s, want at least %s (exponential backoff between attempts)", elapsed, wantMinElapsed)
+	}
+}
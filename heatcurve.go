@@ -0,0 +1,219 @@
+package nilan
+
+import (
+	"context"
+	"time"
+)
+
+// heatCurveHysteresisX10 is the minimum change (in x10 C units, i.e. 1 C)
+// a newly computed setpoint must have over the last one written before
+// HeatCurve bothers sending it to the device.
+const heatCurveHysteresisX10 = 10
+
+// HeatCurvePoint is one knot of a piecewise-linear weather compensation
+// curve: at OutdoorTemperature the supply flow setpoint is
+// SupplyTemperature. Both are in x10 C units, matching the registers they
+// come from.
+type HeatCurvePoint struct {
+	OutdoorTemperature int
+	SupplyTemperature  int
+}
+
+// HeatCurve periodically reads the outdoor temperature and writes a supply
+// flow setpoint computed from a classic weather-compensated heating curve,
+// the way a hydronic heating controller would. Points defines the curve's
+// shape, ParallelShift moves the whole curve up or down, and
+// RoomTemperatureGain adds a proportional correction based on how far the
+// room is from its own setpoint.
+type HeatCurve struct {
+	Controller *Controller
+
+	// Points defines the piecewise-linear curve, sorted by
+	// OutdoorTemperature ascending. Outdoor temperatures outside the
+	// covered range clamp to the nearest end point.
+	Points []HeatCurvePoint
+
+	// ParallelShift is added to the curve's output, in x10 C units. This
+	// is the classic "parallel displacement" knob on a heating curve.
+	ParallelShift int
+
+	// MinSupplyTemperature and MaxSupplyTemperature clamp the computed
+	// setpoint, in x10 C units. Zero disables the corresponding clamp.
+	MinSupplyTemperature int
+	MaxSupplyTemperature int
+
+	// RoomTemperatureGain, if non-zero, adds
+	// RoomTemperatureGain * (DesiredRoomTemperature - RoomTemperature) to
+	// the setpoint, giving a simple P-controller correction for room
+	// temperature error.
+	RoomTemperatureGain float64
+
+	// NightSetback, if non-zero, is subtracted from the computed setpoint
+	// during [NightSetbackStart, NightSetbackEnd) each day, both measured
+	// as a duration since local midnight (e.g. 22*time.Hour to
+	// 6*time.Hour for a setback spanning midnight).
+	NightSetback      int
+	NightSetbackStart time.Duration
+	NightSetbackEnd   time.Duration
+
+	lastSetpoint *int
+}
+
+// Run starts polling the device every interval, writing a new supply flow
+// setpoint whenever HeatCurve computes one that differs from the last
+// written value by at least 1 C. Errors from reading or writing the
+// device are sent on the returned channel rather than stopping the loop.
+// The channel is closed when ctx is done.
+func (hc *HeatCurve) Run(ctx context.Context, interval time.Duration) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := hc.tick(now); err != nil {
+					select {
+					case errs <- err:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return errs
+}
+
+// tick reads whatever the curve needs, computes the setpoint for now, and
+// writes it to the device if it has moved enough to clear the hysteresis
+// band.
+func (hc *HeatCurve) tick(now time.Time) error {
+	c := hc.Controller
+
+	outdoor, err := c.FetchValue(1, OutdoorTemperatureRegister)
+	if err != nil {
+		return err
+	}
+
+	var roomError int
+	if hc.RoomTemperatureGain != 0 {
+		roomError, err = hc.roomTemperatureError(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	setpoint := hc.computeSetpoint(int(int16(outdoor)), roomError, now)
+
+	if hc.lastSetpoint != nil && absInt(setpoint-*hc.lastSetpoint) < heatCurveHysteresisX10 {
+		return nil
+	}
+
+	if err := c.SendSettings(Settings{SetpointSupplyTemperature: &setpoint}); err != nil {
+		return err
+	}
+	hc.lastSetpoint = &setpoint
+	return nil
+}
+
+// roomTemperatureError fetches DesiredRoomTemperature and the currently
+// active room temperature sensor reading and returns their difference, in
+// x10 C units.
+func (hc *HeatCurve) roomTemperatureError(c *Controller) (int, error) {
+	roomTemperatureRegister, err := c.roomTemperatureRegister()
+	if err != nil {
+		return 0, err
+	}
+
+	values, err := c.FetchRegisterValues(1, []Register{DesiredRoomTemperatureRegister, roomTemperatureRegister})
+	if err != nil {
+		return 0, err
+	}
+
+	desired := int(int16(values[DesiredRoomTemperatureRegister]))
+	actual := int(int16(values[roomTemperatureRegister]))
+	return desired - actual, nil
+}
+
+// computeSetpoint applies the curve, parallel shift, room error term,
+// night setback and min/max clamps, in that order, to produce the supply
+// flow setpoint (x10 C) for the given outdoor temperature (x10 C) at now.
+func (hc *HeatCurve) computeSetpoint(outdoor int, roomError int, now time.Time) int {
+	setpoint := hc.interpolate(outdoor) + hc.ParallelShift
+
+	if hc.RoomTemperatureGain != 0 {
+		setpoint += int(hc.RoomTemperatureGain * float64(roomError))
+	}
+
+	if hc.NightSetback != 0 && hc.inNightSetback(now) {
+		setpoint -= hc.NightSetback
+	}
+
+	if hc.MinSupplyTemperature != 0 && setpoint < hc.MinSupplyTemperature {
+		setpoint = hc.MinSupplyTemperature
+	}
+	if hc.MaxSupplyTemperature != 0 && setpoint > hc.MaxSupplyTemperature {
+		setpoint = hc.MaxSupplyTemperature
+	}
+
+	return setpoint
+}
+
+// interpolate returns the curve's supply temperature for outdoor,
+// clamping to the nearest point outside the covered range.
+func (hc *HeatCurve) interpolate(outdoor int) int {
+	points := hc.Points
+	if len(points) == 0 {
+		return 0
+	}
+
+	if outdoor <= points[0].OutdoorTemperature {
+		return points[0].SupplyTemperature
+	}
+	last := points[len(points)-1]
+	if outdoor >= last.OutdoorTemperature {
+		return last.SupplyTemperature
+	}
+
+	for i := 1; i < len(points); i++ {
+		next := points[i]
+		if outdoor > next.OutdoorTemperature {
+			continue
+		}
+		prev := points[i-1]
+		span := next.OutdoorTemperature - prev.OutdoorTemperature
+		if span == 0 {
+			return prev.SupplyTemperature
+		}
+		fraction := float64(outdoor-prev.OutdoorTemperature) / float64(span)
+		return prev.SupplyTemperature + int(fraction*float64(next.SupplyTemperature-prev.SupplyTemperature))
+	}
+
+	return last.SupplyTemperature
+}
+
+// inNightSetback reports whether now's local time of day falls within
+// [NightSetbackStart, NightSetbackEnd), wrapping past midnight when Start
+// is after End.
+func (hc *HeatCurve) inNightSetback(now time.Time) bool {
+	if hc.NightSetbackStart == hc.NightSetbackEnd {
+		return false
+	}
+
+	timeOfDay := time.Duration(now.Hour())*time.Hour +
+		time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+
+	if hc.NightSetbackStart < hc.NightSetbackEnd {
+		return timeOfDay >= hc.NightSetbackStart && timeOfDay < hc.NightSetbackEnd
+	}
+	return timeOfDay >= hc.NightSetbackStart || timeOfDay < hc.NightSetbackEnd
+}
@@ -0,0 +1,108 @@
+package nilan
+
+import (
+	"testing"
+	"time"
+)
+
+func testCurve() []HeatCurvePoint {
+	return []HeatCurvePoint{
+		{OutdoorTemperature: -200, SupplyTemperature: 400},
+		{OutdoorTemperature: 0, SupplyTemperature: 300},
+		{OutdoorTemperature: 200, SupplyTemperature: 200},
+	}
+}
+
+func TestHeatCurveInterpolate(t *testing.T) {
+	hc := &HeatCurve{Points: testCurve()}
+
+	cases := []struct {
+		name    string
+		outdoor int
+		want    int
+	}{
+		{"below range clamps to first point", -300, 400},
+		{"at first point", -200, 400},
+		{"midpoint of first span", -100, 350},
+		{"at knot", 0, 300},
+		{"midpoint of second span", 100, 250},
+		{"at last point", 200, 200},
+		{"above range clamps to last point", 300, 200},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := hc.interpolate(tc.outdoor); got != tc.want {
+				t.Errorf("interpolate(%d) = %d, want %d", tc.outdoor, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeatCurveInterpolateEmpty(t *testing.T) {
+	hc := &HeatCurve{}
+	if got := hc.interpolate(0); got != 0 {
+		t.Errorf("interpolate with no points = %d, want 0", got)
+	}
+}
+
+func TestHeatCurveComputeSetpointClamps(t *testing.T) {
+	hc := &HeatCurve{
+		Points:               testCurve(),
+		MinSupplyTemperature: 220,
+		MaxSupplyTemperature: 380,
+	}
+
+	if got := hc.computeSetpoint(-300, 0, time.Time{}); got != 380 {
+		t.Errorf("computeSetpoint below curve range = %d, want 380 (MaxSupplyTemperature clamp)", got)
+	}
+	if got := hc.computeSetpoint(300, 0, time.Time{}); got != 220 {
+		t.Errorf("computeSetpoint above curve range = %d, want 220 (MinSupplyTemperature clamp)", got)
+	}
+}
+
+func TestHeatCurveComputeSetpointParallelShiftAndRoomGain(t *testing.T) {
+	hc := &HeatCurve{
+		Points:              testCurve(),
+		ParallelShift:       20,
+		RoomTemperatureGain: 0.5,
+	}
+
+	// interpolate(0) == 300, +20 shift, +0.5*10 room error == 325
+	got := hc.computeSetpoint(0, 10, time.Time{})
+	want := 325
+	if got != want {
+		t.Errorf("computeSetpoint() = %d, want %d", got, want)
+	}
+}
+
+func TestHeatCurveInNightSetback(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name  string
+		start time.Duration
+		end   time.Duration
+		now   time.Time
+		want  bool
+	}{
+		{"disabled when start equals end", 22 * time.Hour, 22 * time.Hour, at(23, 0), false},
+		{"same-day window, inside", 8 * time.Hour, 16 * time.Hour, at(12, 0), true},
+		{"same-day window, before start", 8 * time.Hour, 16 * time.Hour, at(7, 0), false},
+		{"same-day window, at end is exclusive", 8 * time.Hour, 16 * time.Hour, at(16, 0), false},
+		{"wraps midnight, before midnight", 22 * time.Hour, 6 * time.Hour, at(23, 0), true},
+		{"wraps midnight, after midnight", 22 * time.Hour, 6 * time.Hour, at(1, 0), true},
+		{"wraps midnight, outside window", 22 * time.Hour, 6 * time.Hour, at(12, 0), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hc := &HeatCurve{NightSetbackStart: tc.start, NightSetbackEnd: tc.end}
+			if got := hc.inNightSetback(tc.now); got != tc.want {
+				t.Errorf("inNightSetback(%v) = %v, want %v", tc.now, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package nilan
+
+// Logger is the interface Controller uses for diagnostic output. It lets
+// callers route nilan's logging into logrus, zap, zerolog or anything
+// else instead of being forced onto the standard library's log package.
+// Controller.Logger defaults to a no-op implementation.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// noopLogger discards everything logged through it.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Infof(format string, args ...interface{})  {}
+func (noopLogger) Warnf(format string, args ...interface{})  {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
+
+// logger returns c.Logger, falling back to a no-op logger for Controllers
+// built as a bare struct literal instead of via NewController.
+func (c *Controller) logger() Logger {
+	if c.Logger == nil {
+		return noopLogger{}
+	}
+	return c.Logger
+}
@@ -0,0 +1,87 @@
+package nilan
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/goburrow/modbus"
+)
+
+// fakeLogger records every call made through it so tests can assert on
+// which trace levels a code path actually used.
+type fakeLogger struct {
+	debug []string
+	info  []string
+	warn  []string
+	error []string
+}
+
+func (l *fakeLogger) Debugf(format string, args ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Infof(format string, args ...interface{}) {
+	l.info = append(l.info, fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Warnf(format string, args ...interface{}) {
+	l.warn = append(l.warn, fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Errorf(format string, args ...interface{}) {
+	l.error = append(l.error, fmt.Sprintf(format, args...))
+}
+
+func TestControllerLoggerReceivesTransactionTrace(t *testing.T) {
+	c := newTestController()
+	c.dial = func(slaveID byte) (modbusConnection, error) { return &fakeConnection{}, nil }
+	logger := &fakeLogger{}
+	c.Logger = logger
+
+	if err := c.withRetry(1, func(client modbus.Client) error { return nil }); err != nil {
+		t.Fatalf("withRetry() = %v, want nil", err)
+	}
+
+	if len(logger.debug) == 0 {
+		t.Error("withRetry succeeded but Logger.Debugf was never called")
+	}
+	if len(logger.warn) != 0 {
+		t.Errorf("withRetry succeeded but Logger.Warnf was called: %v", logger.warn)
+	}
+}
+
+func TestControllerLoggerReceivesWarnOnFailedAttempt(t *testing.T) {
+	c := newTestController()
+	c.dial = func(slaveID byte) (modbusConnection, error) { return &fakeConnection{}, nil }
+	logger := &fakeLogger{}
+	c.Logger = logger
+
+	attempt := 0
+	err := c.withRetry(1, func(client modbus.Client) error {
+		attempt++
+		if attempt < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("withRetry() = %v, want nil", err)
+	}
+	if len(logger.warn) != 1 {
+		t.Errorf("got %d Logger.Warnf calls, want 1 for the single failed attempt", len(logger.warn))
+	}
+}
+
+func TestSendSettingsLogsInfof(t *testing.T) {
+	c := newTestController()
+	c.dial = func(slaveID byte) (modbusConnection, error) { return &fakeConnection{}, nil }
+	logger := &fakeLogger{}
+	c.Logger = logger
+
+	if err := c.SendSettings(Settings{}); err != nil {
+		t.Fatalf("SendSettings() = %v, want nil", err)
+	}
+
+	if len(logger.info) != 1 {
+		t.Errorf("got %d Logger.Infof calls, want 1", len(logger.info))
+	}
+}
@@ -0,0 +1,144 @@
+// Package nilanprom exposes a nilan.Controller as a prometheus.Collector,
+// scraping every non-reserved register in nilan.RegisterCatalog and
+// reporting it as a correctly-scaled gauge.
+package nilanprom
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pjuzeliunas/nilan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector reads every non-reserved register in nilan.RegisterCatalog from
+// a nilan.Controller, grouped by the Modbus slave ID each register lives
+// on, and reports them as Prometheus gauges.
+type Collector struct {
+	controller *nilan.Controller
+	bySlave    map[byte][]nilan.RegisterSpec
+	descs      map[nilan.Register]*prometheus.Desc
+	latency    *prometheus.HistogramVec
+}
+
+// NewCollector builds a Collector that reads the catalog's non-reserved
+// registers from controller, one FetchRegisterValues call per slave ID the
+// catalog references. It also wires itself into controller.OnTransaction
+// (chaining any hook already set) to surface a
+// nilan_transaction_duration_seconds histogram, labeled by slave and
+// outcome.
+func NewCollector(controller *nilan.Controller) *Collector {
+	col := &Collector{
+		controller: controller,
+		descs:      make(map[nilan.Register]*prometheus.Desc),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "nilan_transaction_duration_seconds",
+			Help: "Duration of Modbus transactions issued by Controller, labeled by slave ID and outcome.",
+		}, []string{"slave", "outcome"}),
+	}
+
+	var specs []nilan.RegisterSpec
+	for _, spec := range nilan.RegisterCatalog {
+		if spec.Reserved {
+			continue
+		}
+		specs = append(specs, spec)
+		col.descs[spec.Address] = prometheus.NewDesc(
+			"nilan_"+toSnakeCase(spec.Symbol),
+			spec.Description,
+			nil, nil,
+		)
+	}
+	col.bySlave = specsBySlave(specs)
+
+	previous := controller.OnTransaction
+	controller.OnTransaction = func(slaveID byte, duration time.Duration, err error) {
+		if previous != nil {
+			previous(slaveID, duration, err)
+		}
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		col.latency.WithLabelValues(strconv.Itoa(int(slaveID)), outcome).Observe(duration.Seconds())
+	}
+
+	return col
+}
+
+// specsBySlave groups specs by the slave ID they live on.
+func specsBySlave(specs []nilan.RegisterSpec) map[byte][]nilan.RegisterSpec {
+	bySlave := make(map[byte][]nilan.RegisterSpec)
+	for _, spec := range specs {
+		bySlave[spec.Slave] = append(bySlave[spec.Slave], spec)
+	}
+	return bySlave
+}
+
+// Describe implements prometheus.Collector.
+func (col *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range col.descs {
+		ch <- desc
+	}
+	col.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. Each slave's registers are
+// fetched in their own batch via FetchRegisterValues; a read failure on
+// any slave drops every register gauge from the scrape rather than
+// reporting stale or partial values. The latency histogram is still
+// reported.
+func (col *Collector) Collect(ch chan<- prometheus.Metric) {
+	values := make(map[nilan.Register]uint16)
+	for slaveID, specs := range col.bySlave {
+		registers := make([]nilan.Register, len(specs))
+		for i, spec := range specs {
+			registers[i] = spec.Address
+		}
+
+		slaveValues, err := col.controller.FetchRegisterValues(slaveID, registers)
+		if err != nil {
+			col.latency.Collect(ch)
+			return
+		}
+		for register, value := range slaveValues {
+			values[register] = value
+		}
+	}
+	col.latency.Collect(ch)
+
+	for _, specs := range col.bySlave {
+		for _, spec := range specs {
+			raw, ok := values[spec.Address]
+			if !ok {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(col.descs[spec.Address], prometheus.GaugeValue, spec.Decode(raw))
+		}
+	}
+}
+
+// toSnakeCase turns a CamelCase register symbol (e.g. "DHWSetPoint") into a
+// Prometheus-friendly metric suffix (e.g. "dhw_set_point"), treating runs
+// of consecutive uppercase letters as a single acronym (e.g. "T18ReadingGEO"
+// becomes "t18_reading_geo" rather than "t18_reading_g_e_o").
+func toSnakeCase(symbol string) string {
+	runes := []rune(symbol)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && isUpper(r) {
+			prevLower := !isUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+func isUpper(r rune) bool {
+	return r >= 'A' && r <= 'Z'
+}
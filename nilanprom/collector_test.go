@@ -0,0 +1,41 @@
+package nilanprom
+
+import (
+	"testing"
+
+	"github.com/pjuzeliunas/nilan"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"FanSpeed":                       "fan_speed",
+		"DHWSetPoint":                    "dhw_set_point",
+		"DHWTopTankTemperature":          "dhw_top_tank_temperature",
+		"T18ReadingGEO":                  "t18_reading_geo",
+		"T18ReadingAIR9":                 "t18_reading_air9",
+		"MasterTemperatureSensorSetting": "master_temperature_sensor_setting",
+	}
+
+	for in, want := range cases {
+		if got := toSnakeCase(in); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSpecsBySlave(t *testing.T) {
+	specs := []nilan.RegisterSpec{
+		{Address: 1, Slave: 1, Symbol: "A"},
+		{Address: 2, Slave: 4, Symbol: "B"},
+		{Address: 3, Slave: 1, Symbol: "C"},
+	}
+
+	bySlave := specsBySlave(specs)
+
+	if len(bySlave[1]) != 2 {
+		t.Errorf("slave 1: got %d specs, want 2", len(bySlave[1]))
+	}
+	if len(bySlave[4]) != 1 {
+		t.Errorf("slave 4: got %d specs, want 1", len(bySlave[4]))
+	}
+}
@@ -0,0 +1,106 @@
+package nilan
+
+// RegisterType describes how a raw Modbus register word should be
+// interpreted.
+type RegisterType int
+
+const (
+	// RegisterTypeUint16 is a plain unsigned 16-bit value.
+	RegisterTypeUint16 RegisterType = iota
+	// RegisterTypeInt16 is a signed 16-bit value.
+	RegisterTypeInt16
+	// RegisterTypeBool is a 0/1 flag.
+	RegisterTypeBool
+	// RegisterTypeTempC10 is a temperature in Celsius, stored as the value
+	// times 10. Example: 23.5 C is stored as 235.
+	RegisterTypeTempC10
+	// RegisterTypeEnum is a small set of named integer states.
+	RegisterTypeEnum
+)
+
+// RegisterSpec describes a single CTS700 holding register: its address,
+// name, human-readable meaning and how to turn its raw uint16 value into a
+// real-world quantity.
+type RegisterSpec struct {
+	Address Register
+	// Slave is the Modbus slave ID this register lives on. The CTS700
+	// exposes most registers on slave 1, but central heating and
+	// supply-flow registers (see FetchSettings/FetchReadings) sit on
+	// slave 4 instead.
+	Slave       byte
+	Symbol      string
+	Description string
+	Type        RegisterType
+	// Multiplier scales the decoded numeric value, e.g. 0.1 for
+	// RegisterTypeTempC10 registers so Decode returns degrees (e.g. 23.5)
+	// rather than tenths of a degree (235). Zero is treated as 1 (no scaling).
+	Multiplier float64
+	Unit       string
+	// Reserved registers exist on the bus but carry no user-facing value
+	// (manufacturer/model-detection registers); consumers such as
+	// nilanprom skip them.
+	Reserved bool
+}
+
+// Decode turns a raw register word into the real-world value described by
+// the spec (e.g. a temperature in degrees, or 0/1 for booleans).
+func (s RegisterSpec) Decode(raw uint16) float64 {
+	var value float64
+	switch s.Type {
+	case RegisterTypeInt16, RegisterTypeTempC10:
+		value = float64(int16(raw))
+	case RegisterTypeBool:
+		if raw != 0 {
+			value = 1
+		}
+	default:
+		value = float64(raw)
+	}
+
+	multiplier := s.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+	return value * multiplier
+}
+
+// RegisterCatalog lists the CTS700 holding registers this library already
+// exposes via the named Register constants in controller.go, alongside how to decode
+// each one. It is not an exhaustive catalog of every register the CTS700
+// supports, and it does not cover coils; there is no coil read/write path
+// in this library.
+var RegisterCatalog = []RegisterSpec{
+	{Address: FanSpeedRegister, Slave: 1, Symbol: "FanSpeed", Description: "Desired fan speed", Type: RegisterTypeEnum},
+	{Address: DesiredRoomTemperatureRegister, Slave: 1, Symbol: "DesiredRoomTemperature", Description: "Desired room temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: MasterTemperatureSensorSettingRegister, Slave: 1, Symbol: "MasterTemperatureSensorSetting", Description: "0: read room temperature from T3, 1: read from Text", Type: RegisterTypeEnum},
+	{Address: T3ExtractAirTemperatureRegister, Slave: 1, Symbol: "T3ExtractAirTemperature", Description: "T3 extract air temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: TextRoomTemperatureRegister, Slave: 1, Symbol: "TextRoomTemperature", Description: "Text room temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: OutdoorTemperatureRegister, Slave: 1, Symbol: "OutdoorTemperature", Description: "Outdoor temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: AverageHumidityRegister, Slave: 1, Symbol: "AverageHumidity", Description: "Average relative humidity", Type: RegisterTypeUint16, Unit: "%"},
+	{Address: ActualHumidityRegister, Slave: 1, Symbol: "ActualHumidity", Description: "Actual relative humidity", Type: RegisterTypeUint16, Unit: "%"},
+	{Address: DHWTopTankTemperatureRegister, Slave: 1, Symbol: "DHWTopTankTemperature", Description: "T11 top DHW tank temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: DHWBottomTankTemperatureRegister, Slave: 1, Symbol: "DHWBottomTankTemperature", Description: "T11 bottom DHW tank temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: DHWSetPointRegister, Slave: 1, Symbol: "DHWSetPoint", Description: "Desired DHW temperature", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: DHWPauseRegister, Slave: 1, Symbol: "DHWPause", Description: "DHW production pause flag", Type: RegisterTypeBool},
+	{Address: DHWPauseDurationRegister, Slave: 1, Symbol: "DHWPauseDuration", Description: "DHW production pause duration", Type: RegisterTypeUint16, Unit: "h"},
+	{Address: CentralHeatingPauseRegister, Slave: 4, Symbol: "CentralHeatingPause", Description: "Central heating pause flag", Type: RegisterTypeBool},
+	{Address: CentralHeatingPauseDurationRegister, Slave: 4, Symbol: "CentralHeatingPauseDuration", Description: "Central heating pause duration", Type: RegisterTypeUint16, Unit: "h"},
+	{Address: CentralHeatingPowerRegister, Slave: 4, Symbol: "CentralHeatingPower", Description: "Central heating on/off", Type: RegisterTypeBool},
+	{Address: VentilationModeRegister, Slave: 1, Symbol: "VentilationMode", Description: "Ventilation mode (0, 1 or 2)", Type: RegisterTypeEnum},
+	{Address: VentilationPauseRegister, Slave: 1, Symbol: "VentilationPause", Description: "Ventilation pause flag", Type: RegisterTypeBool},
+	{Address: SetpointSupplyTemperatureRegisterAIR9, Slave: 4, Symbol: "SetpointSupplyTemperatureAIR9", Description: "Setpoint supply temperature (AIR9 models)", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: SetpointSupplyTemperatureRegisterGEO, Slave: 4, Symbol: "SetpointSupplyTemperatureGEO", Description: "Setpoint supply temperature (GEO models)", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: DeviceTypeGEOReigister, Slave: 4, Symbol: "DeviceTypeGEO", Description: "Holds 8 on GEO models; used only for device-type detection", Type: RegisterTypeEnum, Reserved: true},
+	{Address: DeviceTypeAIR9Register, Slave: 4, Symbol: "DeviceTypeAIR9", Description: "Holds 9 on AIR9 models; used only for device-type detection", Type: RegisterTypeEnum, Reserved: true},
+	{Address: T18ReadingRegisterGEO, Slave: 4, Symbol: "T18ReadingGEO", Description: "T18 supply flow temperature (GEO models)", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: T18ReadingRegisterAIR9, Slave: 4, Symbol: "T18ReadingAIR9", Description: "T18 supply flow temperature (AIR9 models)", Type: RegisterTypeTempC10, Multiplier: 0.1, Unit: "C"},
+	{Address: EventOutdoorFilterWarningRegister, Slave: 1, Symbol: "EventOutdoorFilterWarning", Description: "Outdoor filter warning presence", Type: RegisterTypeBool},
+	{Address: EventExtractFilterWarningRegister, Slave: 1, Symbol: "EventExtractFilterWarning", Description: "Extract filter warning presence", Type: RegisterTypeBool},
+	{Address: EventHeaterOverHeatAlarmRegister, Slave: 1, Symbol: "EventHeaterOverHeatAlarm", Description: "Heater overheat alarm presence", Type: RegisterTypeBool},
+	{Address: EventHeaterFrostWarningRegister, Slave: 1, Symbol: "EventHeaterFrostWarning", Description: "Heater frost warning presence", Type: RegisterTypeBool},
+	{Address: EventHeaterFrostLongAlarmRegister, Slave: 1, Symbol: "EventHeaterFrostLongAlarm", Description: "Heater long frost alarm presence", Type: RegisterTypeBool},
+	{Address: EventHeaterFrostAlarmRegister, Slave: 1, Symbol: "EventHeaterFrostAlarm", Description: "Heater frost alarm presence", Type: RegisterTypeBool},
+	{Address: EventFireThermAlarmRegister, Slave: 1, Symbol: "EventFireThermAlarm", Description: "Brandindgang (fire thermostat) activation status", Type: RegisterTypeBool},
+	{Address: EventKlixonWarningRegister, Slave: 1, Symbol: "EventKlixonWarning", Description: "Klixon warning presence", Type: RegisterTypeBool},
+	{Address: EventCompressHighPressWarning, Slave: 1, Symbol: "EventCompressHighPressWarning", Description: "Compressor high pressure warning presence", Type: RegisterTypeBool},
+}
@@ -0,0 +1,29 @@
+package nilan
+
+import "testing"
+
+func TestRegisterSpecDecode(t *testing.T) {
+	cases := []struct {
+		name string
+		spec RegisterSpec
+		raw  uint16
+		want float64
+	}{
+		{"uint16 default", RegisterSpec{Type: RegisterTypeUint16}, 42, 42},
+		{"int16 positive", RegisterSpec{Type: RegisterTypeInt16}, 42, 42},
+		{"int16 negative", RegisterSpec{Type: RegisterTypeInt16}, 0xFFCE, -50},
+		{"bool zero", RegisterSpec{Type: RegisterTypeBool}, 0, 0},
+		{"bool nonzero", RegisterSpec{Type: RegisterTypeBool}, 1, 1},
+		{"temp_c10 positive scaled", RegisterSpec{Type: RegisterTypeTempC10, Multiplier: 0.1}, 235, 23.5},
+		{"temp_c10 negative scaled", RegisterSpec{Type: RegisterTypeTempC10, Multiplier: 0.1}, 0xFFCE, -5},
+		{"enum default", RegisterSpec{Type: RegisterTypeEnum}, 2, 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.spec.Decode(tc.raw); got != tc.want {
+				t.Errorf("Decode(%#x) = %v, want %v", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package nilan
+
+import (
+	"context"
+	"time"
+)
+
+// humidityDebounce is how many percentage points AverageHumidity or
+// ActualHumidity may move without Subscribe emitting a new Snapshot; only a
+// larger move emits. Humidity sensors are noisy enough that every reading
+// differs slightly.
+const humidityDebounce = 3
+
+// t18Debounce is how many x10-degree units SupplyFlowTemperature (T18)
+// may move without Subscribe emitting a new Snapshot; only a larger move
+// emits.
+const t18Debounce = 5
+
+// Snapshot is one set of readings emitted by Subscribe.
+type Snapshot struct {
+	Readings Readings
+}
+
+// Subscribe polls the device for Readings every interval and sends a
+// Snapshot on the returned channel whenever something beyond sensor noise
+// has changed; AverageHumidity, ActualHumidity and SupplyFlowTemperature
+// are debounced (see humidityDebounce, t18Debounce) since they jitter on
+// every poll. Fetch errors are sent on the error channel instead of
+// stopping the subscription. Both channels are closed when ctx is done.
+func (c *Controller) Subscribe(ctx context.Context, interval time.Duration) (<-chan Snapshot, <-chan error) {
+	out := make(chan Snapshot)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errs)
+
+		var last *Readings
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				readings, err := c.FetchReadings()
+				if err != nil {
+					select {
+					case errs <- err:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+
+				if last != nil && !readingsChanged(last, readings) {
+					continue
+				}
+				last = readings
+
+				select {
+				case out <- Snapshot{Readings: *readings}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, errs
+}
+
+// SubscribeErrors polls the device for Errors every interval and sends on
+// the returned channel only when the error state changes (edge-triggered),
+// so an alarm that stays active doesn't re-fire on every poll. Fetch
+// errors are sent on the error channel instead of stopping the
+// subscription. Both channels are closed when ctx is done.
+func (c *Controller) SubscribeErrors(ctx context.Context, interval time.Duration) (<-chan Errors, <-chan error) {
+	out := make(chan Errors)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errs)
+
+		var last *Errors
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current, err := c.FetchErrors()
+				if err != nil {
+					select {
+					case errs <- err:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+
+				if last != nil && *last == *current {
+					continue
+				}
+				last = current
+
+				select {
+				case out <- *current:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, errs
+}
+
+// readingsChanged reports whether next differs from prev enough to be
+// worth emitting: any change to a non-debounced field, or a debounced
+// field moving by more than its threshold.
+func readingsChanged(prev, next *Readings) bool {
+	if next.RoomTemperature != prev.RoomTemperature ||
+		next.OutdoorTemperature != prev.OutdoorTemperature ||
+		next.DHWTankTopTemperature != prev.DHWTankTopTemperature ||
+		next.DHWTankBottomTemperature != prev.DHWTankBottomTemperature {
+		return true
+	}
+	if absInt(next.AverageHumidity-prev.AverageHumidity) > humidityDebounce ||
+		absInt(next.ActualHumidity-prev.ActualHumidity) > humidityDebounce {
+		return true
+	}
+	if absInt(next.SupplyFlowTemperature-prev.SupplyFlowTemperature) > t18Debounce {
+		return true
+	}
+	return false
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
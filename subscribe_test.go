@@ -0,0 +1,53 @@
+package nilan
+
+import "testing"
+
+func TestReadingsChanged(t *testing.T) {
+	base := Readings{
+		RoomTemperature:          200,
+		OutdoorTemperature:       50,
+		AverageHumidity:          40,
+		ActualHumidity:           40,
+		DHWTankTopTemperature:    450,
+		DHWTankBottomTemperature: 400,
+		SupplyFlowTemperature:    300,
+	}
+
+	cases := []struct {
+		name string
+		next Readings
+		want bool
+	}{
+		{"identical", base, false},
+		{"room temperature changes", withRoomTemperature(base, 201), true},
+		{"humidity within debounce", withAverageHumidity(base, base.AverageHumidity+humidityDebounce), false},
+		{"humidity beyond debounce", withAverageHumidity(base, base.AverageHumidity+humidityDebounce+1), true},
+		{"t18 within debounce", withSupplyFlowTemperature(base, base.SupplyFlowTemperature+t18Debounce), false},
+		{"t18 beyond debounce", withSupplyFlowTemperature(base, base.SupplyFlowTemperature+t18Debounce+1), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prev := base
+			next := tc.next
+			if got := readingsChanged(&prev, &next); got != tc.want {
+				t.Errorf("readingsChanged() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func withRoomTemperature(r Readings, v int) Readings {
+	r.RoomTemperature = v
+	return r
+}
+
+func withAverageHumidity(r Readings, v int) Readings {
+	r.AverageHumidity = v
+	return r
+}
+
+func withSupplyFlowTemperature(r Readings, v int) Readings {
+	r.SupplyFlowTemperature = v
+	return r
+}